@@ -0,0 +1,280 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BackupStorageConfig knows how to turn a backup storage backend choice into
+// the `-backup_storage_*` flags and environment variables that a
+// VttabletProcess needs in order to talk to that backend. The zero value is
+// not usable; build one with NewFileBackupStorageConfig,
+// NewS3BackupStorageConfig, NewGCSBackupStorageConfig or
+// NewCephBackupStorageConfig.
+type BackupStorageConfig struct {
+	// Implementation is the value passed to -backup_storage_implementation,
+	// e.g. "file", "s3", "gcs" or "ceph".
+	Implementation string
+
+	// FileRoot is used by the "file" implementation.
+	FileRoot string
+
+	// Bucket, Root and Region are used by the "s3" and "gcs" implementations.
+	Bucket string
+	Root   string
+	Region string
+
+	// Endpoint overrides the default backend endpoint, used to point the
+	// "s3" implementation at a local mock server instead of real AWS.
+	Endpoint string
+	// ForcePathStyle is required by most S3-compatible mock servers, which
+	// don't support bucket-as-subdomain addressing.
+	ForcePathStyle bool
+
+	// CephConfigFile is used by the "ceph" implementation, which reads
+	// bucket/endpoint/credentials from an ini-style config file on disk.
+	CephConfigFile string
+
+	// AccessKey and SecretKey are wired into proc.Env rather than passed as
+	// flags, mirroring how the real backends pick up credentials.
+	AccessKey string
+	SecretKey string
+}
+
+// NewFileBackupStorageConfig returns the config used by default: backups are
+// written to a directory on local disk.
+func NewFileBackupStorageConfig(fileRoot string) *BackupStorageConfig {
+	return &BackupStorageConfig{
+		Implementation: "file",
+		FileRoot:       fileRoot,
+	}
+}
+
+// NewS3BackupStorageConfig returns a config for the S3 backend. Passing a
+// non-empty endpoint (as StartMockS3Server does) points vttablet at a local
+// mock server instead of real AWS, so backup/restore tests don't need cloud
+// credentials.
+func NewS3BackupStorageConfig(bucket, root, region, endpoint string) *BackupStorageConfig {
+	return &BackupStorageConfig{
+		Implementation: "s3",
+		Bucket:         bucket,
+		Root:           root,
+		Region:         region,
+		Endpoint:       endpoint,
+		ForcePathStyle: endpoint != "",
+		AccessKey:      "fake-access-key",
+		SecretKey:      "fake-secret-key",
+	}
+}
+
+// NewGCSBackupStorageConfig returns a config for the GCS backend.
+func NewGCSBackupStorageConfig(bucket, root string) *BackupStorageConfig {
+	return &BackupStorageConfig{
+		Implementation: "gcs",
+		Bucket:         bucket,
+		Root:           root,
+	}
+}
+
+// NewCephBackupStorageConfig returns a config for the Ceph backend, which is
+// configured entirely through an on-disk config file rather than flags.
+func NewCephBackupStorageConfig(configFile string) *BackupStorageConfig {
+	return &BackupStorageConfig{
+		Implementation: "ceph",
+		CephConfigFile: configFile,
+	}
+}
+
+// Flags returns the -backup_storage_* arguments that should be appended to a
+// vttablet/vtctld command line for this backend.
+func (cfg *BackupStorageConfig) Flags() []string {
+	args := []string{"-backup_storage_implementation", cfg.Implementation}
+	switch cfg.Implementation {
+	case "file":
+		args = append(args, "-file_backup_storage_root", cfg.FileRoot)
+	case "s3":
+		args = append(args,
+			"-s3_backup_storage_bucket", cfg.Bucket,
+			"-s3_backup_storage_root", cfg.Root,
+			"-s3_backup_aws_region", cfg.Region,
+		)
+		if cfg.Endpoint != "" {
+			args = append(args, "-s3_backup_storage_endpoint", cfg.Endpoint)
+		}
+		if cfg.ForcePathStyle {
+			args = append(args, "-s3_backup_force_path_style")
+		}
+	case "gcs":
+		args = append(args,
+			"-gcs_backup_storage_bucket", cfg.Bucket,
+			"-gcs_backup_storage_root", cfg.Root,
+		)
+	case "ceph":
+		args = append(args, "-ceph_backup_storage_config", cfg.CephConfigFile)
+	}
+	return args
+}
+
+// Env returns the environment variables that should be merged into
+// proc.Env so the backend's client library can pick up credentials.
+func (cfg *BackupStorageConfig) Env() []string {
+	switch cfg.Implementation {
+	case "s3":
+		return []string{
+			fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", cfg.AccessKey),
+			fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", cfg.SecretKey),
+		}
+	default:
+		return nil
+	}
+}
+
+// StartMockS3Server starts an in-process httptest server that accepts the
+// handful of S3 request shapes the backup engine actually issues (PUT/GET
+// object, list) and stores objects under dir on local disk. It returns the
+// config to hand to NewS3BackupStorageConfig's endpoint argument along with
+// a func to shut the server down; callers are responsible for calling that
+// func during test cleanup.
+func StartMockS3Server(dir string) (endpoint string, shutdown func(), err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", mockS3Handler(dir))
+	server := httptest.NewServer(mux)
+	return server.URL, server.Close, nil
+}
+
+// mockS3Handler implements just enough of the S3 API (PUT/GET/DELETE on
+// /bucket/key, plus a ListObjectsV2 response for GETs against a
+// bucket/prefix) for the backup/restore engine to round-trip files through
+// local disk instead of a real bucket.
+func mockS3Handler(dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		objectPath, err := resolveObjectPath(dir, r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			f, err := os.Create(objectPath)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer f.Close()
+			if _, err := f.ReadFrom(r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			if r.URL.Query().Get("list-type") == "2" {
+				listObjectsV2(w, dir, r.URL.Query().Get("prefix"))
+				return
+			}
+			http.ServeFile(w, r, objectPath)
+		case http.MethodDelete:
+			os.Remove(objectPath)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// resolveObjectPath joins dir with the (URL-decoded) request path and
+// rejects the result if a "../" segment in urlPath would have made it land
+// outside dir. PUT and DELETE write straight to whatever path they're
+// given, so unlike GET (which net/http.ServeFile already guards) they need
+// this check done explicitly.
+func resolveObjectPath(dir, urlPath string) (string, error) {
+	objectPath := filepath.Clean(filepath.Join(dir, urlPath))
+	if objectPath != dir && !strings.HasPrefix(objectPath, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("request path %q escapes storage root", urlPath)
+	}
+	return objectPath, nil
+}
+
+// s3ListBucketResult is the subset of the ListObjectsV2 XML response shape
+// that the backup engine's restore path needs in order to enumerate
+// existing backups under a prefix.
+type s3ListBucketResult struct {
+	XMLName  xml.Name   `xml:"ListBucketResult"`
+	Name     string     `xml:"Name"`
+	Prefix   string     `xml:"Prefix"`
+	Contents []s3Object `xml:"Contents"`
+}
+
+// s3Object is a single <Contents> entry in a ListObjectsV2 response.
+type s3Object struct {
+	Key string `xml:"Key"`
+}
+
+// listObjectsV2 writes a minimal ListObjectsV2 response listing every
+// regular file found under dir/prefix, keyed the same way PUT/GET/DELETE
+// address objects. It's enough for a restore path that lists a prefix to
+// find the backups stored there; it doesn't support pagination or the
+// delimiter/CommonPrefixes "directory" mode.
+func listObjectsV2(w http.ResponseWriter, dir, prefix string) {
+	root, err := resolveObjectPath(dir, prefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := s3ListBucketResult{Prefix: prefix}
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		key, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		result.Contents = append(result.Contents, s3Object{Key: filepath.ToSlash(key)})
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err := xml.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(append([]byte(xml.Header), body...))
+}
@@ -0,0 +1,38 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+// MysqlManager is the common surface that cluster tests drive a MySQL
+// instance through, regardless of whether it's backed by forking a
+// mysqlctl binary per call (MysqlctlProcess) or by a resident mysqlctld
+// daemon driven over gRPC (MysqlctldProcess).
+type MysqlManager interface {
+	// InitDb creates a fresh data directory and starts mysqld against it.
+	InitDb() error
+	// Start starts mysqld against an already-initialized data directory.
+	Start() error
+	// Stop stops mysqld.
+	Stop() error
+	// ReinitConfig regenerates my.cnf from the tablet's current config
+	// without requiring a restart.
+	ReinitConfig() error
+}
+
+var (
+	_ MysqlManager = (*MysqlctlProcess)(nil)
+	_ MysqlManager = (*MysqlctldProcess)(nil)
+)
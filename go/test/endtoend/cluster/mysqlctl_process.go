@@ -32,6 +32,8 @@ type MysqlctlProcess struct {
 	TabletUID    int
 	MySQLPort    int
 	InitDBFile   string
+
+	Supervisor *ProcessSupervisor
 }
 
 // InitDb executes mysqlctl command to add cell info
@@ -47,17 +49,29 @@ func (mysqlctl *MysqlctlProcess) InitDb() (err error) {
 	return tmpProcess.Run()
 }
 
-// Start executes mysqlctl command to start mysql instance
+// Start executes mysqlctl command to start mysql instance against an
+// already-initialized data directory. Its output is captured to a log file
+// and tracked through a ProcessSupervisor so a failure can be diagnosed
+// with mysqlctl.Supervisor.Tail(n) instead of scrolling the test log.
+// Callers must call TearDown once the instance is no longer needed so the
+// log file gets closed.
 func (mysqlctl *MysqlctlProcess) Start() (err error) {
-	tmpProcess := exec.Command(
-		mysqlctl.Binary,
+	args := []string{
 		"-log_dir", mysqlctl.LogDirectory,
 		"-tablet_uid", fmt.Sprintf("%d", mysqlctl.TabletUID),
 		"-mysql_port", fmt.Sprintf("%d", mysqlctl.MySQLPort),
-		"init",
-		"-init_db_sql_file", mysqlctl.InitDBFile,
-	)
-	return tmpProcess.Run()
+		"start",
+	}
+	if mysqlctl.Supervisor == nil {
+		mysqlctl.Supervisor, err = NewProcessSupervisor(
+			fmt.Sprintf("mysqlctl-%d", mysqlctl.TabletUID), mysqlctl.LogDirectory, 0,
+			func() *exec.Cmd { return exec.Command(mysqlctl.Binary, args...) },
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return mysqlctl.Supervisor.RunOnce()
 }
 
 // Stop executes mysqlctl command to stop mysql instance
@@ -70,6 +84,26 @@ func (mysqlctl *MysqlctlProcess) Stop() (err error) {
 	return tmpProcess.Start()
 }
 
+// TearDown releases the log file opened on behalf of Start's
+// ProcessSupervisor. It's a no-op if Start was never called.
+func (mysqlctl *MysqlctlProcess) TearDown() error {
+	if mysqlctl.Supervisor == nil {
+		return nil
+	}
+	return mysqlctl.Supervisor.TearDown()
+}
+
+// ReinitConfig executes mysqlctl command to regenerate my.cnf
+func (mysqlctl *MysqlctlProcess) ReinitConfig() (err error) {
+	tmpProcess := exec.Command(
+		mysqlctl.Binary,
+		"-tablet_uid", fmt.Sprintf("%d", mysqlctl.TabletUID),
+		"-mysql_port", fmt.Sprintf("%d", mysqlctl.MySQLPort),
+		"reinit_config",
+	)
+	return tmpProcess.Run()
+}
+
 // MysqlCtlProcessInstance returns a Mysqlctl handle for mysqlctl process
 // configured with the given Config.
 func MysqlCtlProcessInstance(tabletUID int, mySQLPort int, tmpDirectory string) *MysqlctlProcess {
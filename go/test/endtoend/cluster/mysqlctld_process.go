@@ -0,0 +1,182 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"time"
+
+	"vitess.io/vitess/go/vt/mysqlctl/mysqlctlclient"
+)
+
+// MysqlctldProcess is a handle for a resident mysqlctld daemon. Unlike
+// MysqlctlProcess, which forks a fresh mysqlctl binary for every operation,
+// MysqlctldProcess launches the daemon once and drives it over its gRPC API
+// for the rest of the handle's lifetime, so repeated Start/Stop cycles in a
+// test don't each pay fork+init cost.
+type MysqlctldProcess struct {
+	Name         string
+	Binary       string
+	LogDirectory string
+	TabletUID    int
+	MySQLPort    int
+	GrpcPort     int
+	InitDBFile   string
+	ExtraArgs    []string
+
+	proc *exec.Cmd
+	exit chan error
+
+	client mysqlctlclient.MysqlctlClient
+}
+
+// MysqlCtldProcessInstance returns a MysqlctldProcess handle configured for
+// the given tablet, analogous to MysqlCtlProcessInstance.
+func MysqlCtldProcessInstance(tabletUID, mySQLPort, grpcPort int, tmpDirectory string) *MysqlctldProcess {
+	return &MysqlctldProcess{
+		Name:         "mysqlctld",
+		Binary:       "mysqlctld",
+		LogDirectory: tmpDirectory,
+		InitDBFile:   path.Join(os.Getenv("VTROOT"), "/config/init_db.sql"),
+		TabletUID:    tabletUID,
+		MySQLPort:    mySQLPort,
+		GrpcPort:     grpcPort,
+	}
+}
+
+// launch forks the mysqlctld daemon and keeps it resident. It is a no-op if
+// the daemon is already running.
+func (mysqlctld *MysqlctldProcess) launch(initialize bool) error {
+	if mysqlctld.proc != nil {
+		return nil
+	}
+	args := []string{
+		"-log_dir", mysqlctld.LogDirectory,
+		"-tablet_uid", fmt.Sprintf("%d", mysqlctld.TabletUID),
+		"-mysql_port", fmt.Sprintf("%d", mysqlctld.MySQLPort),
+		"-grpc_port", fmt.Sprintf("%d", mysqlctld.GrpcPort),
+	}
+	if initialize {
+		args = append(args, "-init_db_sql_file", mysqlctld.InitDBFile)
+	}
+	args = append(args, mysqlctld.ExtraArgs...)
+
+	mysqlctld.proc = exec.Command(mysqlctld.Binary, args...)
+	mysqlctld.proc.Stderr = os.Stderr
+	mysqlctld.proc.Stdout = os.Stdout
+	mysqlctld.proc.Env = append(mysqlctld.proc.Env, os.Environ()...)
+
+	if err := mysqlctld.proc.Start(); err != nil {
+		mysqlctld.proc = nil
+		return err
+	}
+
+	mysqlctld.exit = make(chan error)
+	go func() {
+		mysqlctld.exit <- mysqlctld.proc.Wait()
+	}()
+
+	return mysqlctld.waitForGrpcReady()
+}
+
+// waitForGrpcReady blocks until the daemon's gRPC server is accepting
+// connections, or the daemon exits prematurely.
+func (mysqlctld *MysqlctldProcess) waitForGrpcReady() error {
+	addr := fmt.Sprintf("localhost:%d", mysqlctld.GrpcPort)
+	timeout := time.Now().Add(30 * time.Second)
+	for time.Now().Before(timeout) {
+		client, err := mysqlctlclient.New("grpc", addr)
+		if err == nil {
+			mysqlctld.client = client
+			return nil
+		}
+		select {
+		case err := <-mysqlctld.exit:
+			mysqlctld.proc = nil
+			mysqlctld.exit = nil
+			return fmt.Errorf("mysqlctld %d exited prematurely (err: %s)", mysqlctld.TabletUID, err)
+		default:
+			time.Sleep(300 * time.Millisecond)
+		}
+	}
+	return fmt.Errorf("mysqlctld %d timed out waiting for gRPC server", mysqlctld.TabletUID)
+}
+
+// InitDb launches the resident daemon against a fresh data directory and
+// starts mysqld.
+func (mysqlctld *MysqlctldProcess) InitDb() error {
+	if err := mysqlctld.launch(true); err != nil {
+		return err
+	}
+	return mysqlctld.client.Start(context.Background())
+}
+
+// Start launches the resident daemon, if not already running, and starts
+// mysqld against an already-initialized data directory.
+func (mysqlctld *MysqlctldProcess) Start() error {
+	if err := mysqlctld.launch(false); err != nil {
+		return err
+	}
+	return mysqlctld.client.Start(context.Background())
+}
+
+// Stop asks the resident daemon to shut mysqld down. The daemon itself
+// keeps running so a later Start call can reuse it without paying fork cost
+// again.
+func (mysqlctld *MysqlctldProcess) Stop() error {
+	if mysqlctld.client == nil {
+		return nil
+	}
+	return mysqlctld.client.Shutdown(context.Background(), true)
+}
+
+// ReinitConfig asks the resident daemon to regenerate my.cnf without
+// restarting mysqld.
+func (mysqlctld *MysqlctldProcess) ReinitConfig() error {
+	if mysqlctld.client == nil {
+		return fmt.Errorf("mysqlctld %d is not running", mysqlctld.TabletUID)
+	}
+	return mysqlctld.client.ReinitConfig(context.Background())
+}
+
+// TearDown shuts down the resident mysqlctld daemon itself, for use at the
+// end of a test when the daemon won't be reused.
+func (mysqlctld *MysqlctldProcess) TearDown() error {
+	if mysqlctld.client != nil {
+		mysqlctld.client.Close()
+		mysqlctld.client = nil
+	}
+	if mysqlctld.proc == nil || mysqlctld.exit == nil {
+		return nil
+	}
+	mysqlctld.proc.Process.Signal(syscall.SIGTERM)
+
+	select {
+	case <-mysqlctld.exit:
+		mysqlctld.proc = nil
+		return nil
+	case <-time.After(10 * time.Second):
+		mysqlctld.proc.Process.Kill()
+		mysqlctld.proc = nil
+		return <-mysqlctld.exit
+	}
+}
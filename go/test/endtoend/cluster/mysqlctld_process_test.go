@@ -0,0 +1,68 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMysqlctldProcessSurvivesPrematureExit simulates a mysqlctld daemon
+// that exits before its gRPC server comes up (Binary is /bin/false, which
+// forks and exits immediately) and checks that a later call retries
+// instead of hitting launch's proc != nil no-op and calling methods on the
+// nil client left behind by the failed attempt.
+func TestMysqlctldProcessSurvivesPrematureExit(t *testing.T) {
+	dir := t.TempDir()
+	mysqlctld := &MysqlctldProcess{
+		Name:         "mysqlctld",
+		Binary:       "/bin/false",
+		LogDirectory: dir,
+		TabletUID:    999,
+		MySQLPort:    19000,
+		GrpcPort:     19001,
+	}
+
+	if err := mysqlctld.InitDb(); err == nil {
+		t.Fatalf("InitDb() succeeded against a daemon that exits immediately, want an error")
+	}
+	if mysqlctld.proc != nil {
+		t.Fatalf("proc is still set after a premature exit, want nil so a later call retries")
+	}
+	if mysqlctld.exit != nil {
+		t.Fatalf("exit channel is still set after a premature exit, want nil")
+	}
+
+	// Before the fix, this call hit the proc != nil no-op in launch and
+	// called mysqlctld.client.Start on a nil client, panicking.
+	if err := mysqlctld.Start(); err == nil {
+		t.Fatalf("Start() succeeded against a daemon that exits immediately, want an error")
+	}
+
+	// Before the fix, TearDown would also block forever on <-mysqlctld.exit
+	// here, since that channel's one value was already drained above.
+	done := make(chan error, 1)
+	go func() { done <- mysqlctld.TearDown() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("TearDown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TearDown did not return, want it to be a no-op once proc/exit are nil")
+	}
+}
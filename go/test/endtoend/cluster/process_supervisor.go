@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"vitess.io/vitess/go/vt/log"
+)
+
+// ProcessSupervisor manages a single named subprocess on behalf of a cluster
+// test: it tees the process's stdout/stderr to a log file under LogDir
+// instead of the test runner's terminal, tracks whether the process is
+// still alive, and (for long-running processes started with Start) can
+// auto-restart it up to MaxRestarts times if it exits before TearDown is
+// called.
+type ProcessSupervisor struct {
+	Name        string
+	LogDir      string
+	MaxRestarts int
+
+	// newCmd builds a fresh *exec.Cmd for each (re)start; exec.Cmd can't be
+	// reused once it has been run.
+	newCmd func() *exec.Cmd
+
+	mu       sync.Mutex
+	logPath  string
+	logFile  *os.File
+	cmd      *exec.Cmd
+	running  bool
+	exitCode int
+	restarts int
+	torndown bool
+}
+
+// NewProcessSupervisor creates a supervisor that logs to
+// <logDir>/<name>.log and, once started, builds new processes via newCmd.
+func NewProcessSupervisor(name string, logDir string, maxRestarts int, newCmd func() *exec.Cmd) (*ProcessSupervisor, error) {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, err
+	}
+	logPath := path.Join(logDir, fmt.Sprintf("%s.log", name))
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &ProcessSupervisor{
+		Name:        name,
+		LogDir:      logDir,
+		MaxRestarts: maxRestarts,
+		newCmd:      newCmd,
+		logPath:     logPath,
+		logFile:     logFile,
+	}, nil
+}
+
+// Start launches the process and keeps watching it in the background,
+// restarting it (up to MaxRestarts times) if it exits before TearDown is
+// called.
+func (s *ProcessSupervisor) Start() error {
+	return s.launch()
+}
+
+func (s *ProcessSupervisor) launch() error {
+	cmd := s.newCmd()
+	cmd.Stdout = s.logFile
+	cmd.Stderr = s.logFile
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cmd = cmd
+	s.running = true
+	s.mu.Unlock()
+
+	go s.watch(cmd)
+	return nil
+}
+
+func (s *ProcessSupervisor) watch(cmd *exec.Cmd) {
+	err := cmd.Wait()
+
+	s.mu.Lock()
+	s.running = false
+	s.exitCode = exitCodeOf(err)
+	torndown := s.torndown
+	outOfRestarts := s.restarts >= s.MaxRestarts
+	if !torndown && !outOfRestarts {
+		s.restarts++
+	}
+	s.mu.Unlock()
+
+	if torndown || outOfRestarts {
+		return
+	}
+
+	log.Warningf("process '%s' exited unexpectedly (err: %v), restarting (attempt %d/%d)", s.Name, err, s.restarts, s.MaxRestarts)
+	if err := s.launch(); err != nil {
+		log.Errorf("process '%s' failed to restart: %v", s.Name, err)
+	}
+}
+
+// RunOnce runs the process to completion, teeing its output the same way
+// Start does, and reports its result. It does not watch or restart the
+// process; it's for drivers that fork a short-lived helper binary per
+// operation rather than keeping one resident.
+func (s *ProcessSupervisor) RunOnce() error {
+	cmd := s.newCmd()
+	cmd.Stdout = s.logFile
+	cmd.Stderr = s.logFile
+
+	s.mu.Lock()
+	s.cmd = cmd
+	s.running = true
+	s.mu.Unlock()
+
+	err := cmd.Run()
+
+	s.mu.Lock()
+	s.running = false
+	s.exitCode = exitCodeOf(err)
+	s.mu.Unlock()
+
+	return err
+}
+
+// Alive reports whether the managed process is currently running.
+func (s *ProcessSupervisor) Alive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// ExitCode returns the exit code of the most recently completed run, or 0
+// if the process has never exited.
+func (s *ProcessSupervisor) ExitCode() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitCode
+}
+
+// Tail returns the last n lines captured in the process's log file, for
+// inclusion in a test failure message.
+func (s *ProcessSupervisor) Tail(n int) ([]string, error) {
+	data, err := ioutil.ReadFile(s.logPath)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// TearDown stops watching for crashes and, if the process is still
+// running, signals it to stop and waits for it to exit.
+func (s *ProcessSupervisor) TearDown() error {
+	s.mu.Lock()
+	s.torndown = true
+	cmd := s.cmd
+	running := s.running
+	s.mu.Unlock()
+
+	if running && cmd != nil && cmd.Process != nil {
+		cmd.Process.Signal(syscall.SIGTERM)
+		deadline := time.Now().Add(10 * time.Second)
+		for s.Alive() && time.Now().Before(deadline) {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if s.Alive() {
+			cmd.Process.Kill()
+		}
+	}
+
+	return s.logFile.Close()
+}
+
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
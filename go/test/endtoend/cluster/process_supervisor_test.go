@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestProcessSupervisorRunOnce runs a short-lived command to completion and
+// checks that its output lands in the log file and its exit code is
+// reported, without starting the background watch/restart goroutine.
+func TestProcessSupervisorRunOnce(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewProcessSupervisor("runonce", dir, 0, func() *exec.Cmd {
+		return exec.Command("sh", "-c", "echo hello")
+	})
+	if err != nil {
+		t.Fatalf("NewProcessSupervisor: %v", err)
+	}
+	defer s.TearDown()
+
+	if err := s.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if s.Alive() {
+		t.Fatalf("Alive() = true after RunOnce returned")
+	}
+	if got := s.ExitCode(); got != 0 {
+		t.Fatalf("ExitCode() = %d, want 0", got)
+	}
+
+	lines, err := s.Tail(10)
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	if !strings.Contains(strings.Join(lines, "\n"), "hello") {
+		t.Fatalf("Tail(10) = %v, want it to contain %q", lines, "hello")
+	}
+}
+
+// TestProcessSupervisorRestartsOnCrash checks that Start restarts a process
+// that exits on its own, up to MaxRestarts times, and that TearDown stops
+// further restarts. It counts invocations through a marker file rather than
+// ProcessSupervisor's internal restart counter, since that counter is only
+// safe to read under its own mutex and Alive/ExitCode don't expose it.
+func TestProcessSupervisorRestartsOnCrash(t *testing.T) {
+	dir := t.TempDir()
+	counterPath := filepath.Join(dir, "invocations")
+	s, err := NewProcessSupervisor("flaky", dir, 2, func() *exec.Cmd {
+		return exec.Command("sh", "-c", fmt.Sprintf("echo x >> %s; exit 1", counterPath))
+	})
+	if err != nil {
+		t.Fatalf("NewProcessSupervisor: %v", err)
+	}
+
+	invocations := func() int {
+		data, err := ioutil.ReadFile(counterPath)
+		if err != nil {
+			return 0
+		}
+		return len(strings.Split(strings.TrimRight(string(data), "\n"), "\n"))
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// 1 initial run + MaxRestarts (2) restarts = 3 invocations.
+	deadline := time.Now().Add(5 * time.Second)
+	for invocations() < 3 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := invocations(); got != 3 {
+		t.Fatalf("process ran %d times, want 3 (1 initial + MaxRestarts=2)", got)
+	}
+
+	if err := s.TearDown(); err != nil {
+		t.Fatalf("TearDown: %v", err)
+	}
+
+	afterTearDown := invocations()
+	time.Sleep(200 * time.Millisecond)
+	if got := invocations(); got != afterTearDown {
+		t.Fatalf("process kept restarting after TearDown: %d -> %d", afterTearDown, got)
+	}
+}
+
+// TestProcessSupervisorTearDownClosesLogFile checks that TearDown releases
+// the log file handle opened by NewProcessSupervisor, rather than leaking
+// it for the lifetime of the test process.
+func TestProcessSupervisorTearDownClosesLogFile(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewProcessSupervisor("closer", dir, 0, func() *exec.Cmd {
+		return exec.Command("sh", "-c", "true")
+	})
+	if err != nil {
+		t.Fatalf("NewProcessSupervisor: %v", err)
+	}
+	if err := s.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if err := s.TearDown(); err != nil {
+		t.Fatalf("TearDown: %v", err)
+	}
+	// Double-closing an *os.File returns os.ErrClosed; seeing that here
+	// confirms TearDown already closed it for us instead of leaking it.
+	if err := s.logFile.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("logFile.Close() after TearDown = %v, want %v", err, os.ErrClosed)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// RealtimeStats is the "RealtimeStats" section of a vttablet's /debug/vars,
+// reported by the health check / replication reporter.
+type RealtimeStats struct {
+	HealthError         string `json:"health_error"`
+	SecondsBehindMaster uint32 `json:"seconds_behind_master"`
+	BinlogPlayers       int    `json:"binlog_players_count"`
+}
+
+// TabletVars is a typed view of the subset of a vttablet's /debug/vars that
+// cluster tests care about when polling for state.
+type TabletVars struct {
+	TabletStateName string        `json:"TabletStateName"`
+	TabletType      string        `json:"TabletType"`
+	QPS             []float64     `json:"QPS"`
+	RealtimeStats   RealtimeStats `json:"RealtimeStats"`
+}
+
+// GetVars fetches and parses the vttablet's /debug/vars.
+func (vttablet *VttabletProcess) GetVars() (*TabletVars, error) {
+	resp, err := http.Get(vttablet.VerifyURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s returned status %d", vttablet.VerifyURL, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var vars TabletVars
+	if err := json.Unmarshal(body, &vars); err != nil {
+		return nil, err
+	}
+	return &vars, nil
+}
+
+// waitForVars polls /debug/vars until condition returns true, the process
+// exits, or timeout elapses. It returns the last successfully observed vars
+// even on failure, so callers can include them in a diagnostic error.
+func (vttablet *VttabletProcess) waitForVars(timeout time.Duration, condition func(*TabletVars) bool) (*TabletVars, error) {
+	var last *TabletVars
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if vars, err := vttablet.GetVars(); err == nil {
+			last = vars
+			if condition(vars) {
+				return vars, nil
+			}
+		}
+		if vttablet.Supervisor != nil && !vttablet.Supervisor.Alive() {
+			return last, fmt.Errorf("process '%s' exited prematurely (exit code %d)", vttablet.Name, vttablet.Supervisor.ExitCode())
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+	return last, fmt.Errorf("timed out after %s waiting for tablet '%s' (last vars: %+v)", timeout, vttablet.Name, last)
+}
+
+// WaitForTabletType waits until the tablet reports the given tablet type.
+func (vttablet *VttabletProcess) WaitForTabletType(tabletType string, timeout time.Duration) (*TabletVars, error) {
+	return vttablet.waitForVars(timeout, func(vars *TabletVars) bool {
+		return vars.TabletType == tabletType
+	})
+}
+
+// WaitForHealthy waits until the tablet is SERVING and reports no health
+// error.
+func (vttablet *VttabletProcess) WaitForHealthy(timeout time.Duration) (*TabletVars, error) {
+	return vttablet.waitForVars(timeout, func(vars *TabletVars) bool {
+		return vars.TabletStateName == "SERVING" && vars.RealtimeStats.HealthError == ""
+	})
+}
+
+// WaitForReplicationLag waits until the tablet reports replication lag at
+// or below max.
+func (vttablet *VttabletProcess) WaitForReplicationLag(max time.Duration, timeout time.Duration) (*TabletVars, error) {
+	maxSeconds := uint32(max.Seconds())
+	return vttablet.waitForVars(timeout, func(vars *TabletVars) bool {
+		return vars.RealtimeStats.SecondsBehindMaster <= maxSeconds
+	})
+}
+
+// WaitForBinlogPlayerCount waits until the tablet reports exactly n running
+// binlog players.
+func (vttablet *VttabletProcess) WaitForBinlogPlayerCount(n int, timeout time.Duration) (*TabletVars, error) {
+	return vttablet.waitForVars(timeout, func(vars *TabletVars) bool {
+		return vars.RealtimeStats.BinlogPlayers == n
+	})
+}
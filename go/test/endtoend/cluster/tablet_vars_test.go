@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// varsServer starts an httptest server that always serves body as the
+// vttablet's /debug/vars, and returns a VttabletProcess pointed at it.
+func varsServer(t *testing.T, body string) *VttabletProcess {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return &VttabletProcess{Name: "test", VerifyURL: server.URL}
+}
+
+func TestWaitForTabletType(t *testing.T) {
+	vttablet := varsServer(t, `{"TabletStateName": "NOT_SERVING", "TabletType": "replica"}`)
+	vars, err := vttablet.WaitForTabletType("replica", 2*time.Second)
+	if err != nil {
+		t.Fatalf("WaitForTabletType: %v", err)
+	}
+	if vars.TabletType != "replica" {
+		t.Fatalf("TabletType = %q, want %q", vars.TabletType, "replica")
+	}
+}
+
+func TestWaitForHealthy(t *testing.T) {
+	vttablet := varsServer(t, `{"TabletStateName": "SERVING", "RealtimeStats": {"health_error": ""}}`)
+	vars, err := vttablet.WaitForHealthy(2 * time.Second)
+	if err != nil {
+		t.Fatalf("WaitForHealthy: %v", err)
+	}
+	if vars.TabletStateName != "SERVING" {
+		t.Fatalf("TabletStateName = %q, want %q", vars.TabletStateName, "SERVING")
+	}
+}
+
+func TestWaitForReplicationLag(t *testing.T) {
+	vttablet := varsServer(t, `{"RealtimeStats": {"seconds_behind_master": 2}}`)
+	vars, err := vttablet.WaitForReplicationLag(5*time.Second, 2*time.Second)
+	if err != nil {
+		t.Fatalf("WaitForReplicationLag: %v", err)
+	}
+	if vars.RealtimeStats.SecondsBehindMaster != 2 {
+		t.Fatalf("SecondsBehindMaster = %d, want 2", vars.RealtimeStats.SecondsBehindMaster)
+	}
+}
+
+func TestWaitForBinlogPlayerCount(t *testing.T) {
+	vttablet := varsServer(t, `{"RealtimeStats": {"binlog_players_count": 3}}`)
+	vars, err := vttablet.WaitForBinlogPlayerCount(3, 2*time.Second)
+	if err != nil {
+		t.Fatalf("WaitForBinlogPlayerCount: %v", err)
+	}
+	if vars.RealtimeStats.BinlogPlayers != 3 {
+		t.Fatalf("BinlogPlayers = %d, want 3", vars.RealtimeStats.BinlogPlayers)
+	}
+}
+
+// TestWaitForVarsTimesOut checks that waitForVars gives up and returns an
+// error, including the last observed vars, once timeout elapses without
+// the condition ever being satisfied.
+func TestWaitForVarsTimesOut(t *testing.T) {
+	vttablet := varsServer(t, `{"TabletStateName": "NOT_SERVING"}`)
+	start := time.Now()
+	_, err := vttablet.WaitForHealthy(500 * time.Millisecond)
+	if err == nil {
+		t.Fatalf("WaitForHealthy succeeded, want a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Fatalf("WaitForHealthy returned after %s, want it to wait out the timeout", elapsed)
+	}
+}
+
+// TestWaitForVarsReturnsErrorWhenProcessExits checks that waitForVars gives
+// up immediately (rather than waiting out the full timeout) once it
+// observes the tracked Supervisor is no longer alive.
+func TestWaitForVarsReturnsErrorWhenProcessExits(t *testing.T) {
+	dir := t.TempDir()
+	supervisor, err := NewProcessSupervisor("exited", dir, 0, func() *exec.Cmd {
+		return exec.Command("/bin/false")
+	})
+	if err != nil {
+		t.Fatalf("NewProcessSupervisor: %v", err)
+	}
+	defer supervisor.TearDown()
+	if err := supervisor.RunOnce(); err == nil {
+		t.Fatalf("RunOnce() of /bin/false succeeded, want a nonzero exit")
+	}
+
+	vttablet := &VttabletProcess{
+		Name:       "test",
+		VerifyURL:  "http://127.0.0.1:1/debug/vars", // nothing listens here
+		Supervisor: supervisor,
+	}
+
+	start := time.Now()
+	_, err = vttablet.WaitForHealthy(30 * time.Second)
+	if err == nil {
+		t.Fatalf("WaitForHealthy succeeded against a dead process, want an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("WaitForHealthy took %s to notice the dead process, want it to return promptly", elapsed)
+	}
+}
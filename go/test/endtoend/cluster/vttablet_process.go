@@ -17,15 +17,11 @@ limitations under the License.
 package cluster
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
-	"syscall"
 	"time"
 
 	"vitess.io/vitess/go/vt/log"
@@ -52,22 +48,27 @@ type VttabletProcess struct {
 	HealthCheckInterval         int
 	BackupStorageImplementation string
 	FileBackupStorageRoot       string
-	ServiceMap                  string
-	VtctldAddress               string
-	Directory                   string
-	VerifyURL                   string
+	// BackupStorage configures an alternative backup storage backend
+	// (s3, gcs, ceph). If nil, the legacy BackupStorageImplementation /
+	// FileBackupStorageRoot fields above are used instead.
+	BackupStorage *BackupStorageConfig
+	ServiceMap    string
+	VtctldAddress string
+	Directory     string
+	VerifyURL     string
 	//Extra Args to be set before starting the vttablet process
 	ExtraArgs []string
 
-	proc *exec.Cmd
-	exit chan error
+	// MaxRestarts is how many times Supervisor will auto-restart this
+	// vttablet if it crashes before TearDown is called. 0 disables restarts.
+	MaxRestarts int
+
+	Supervisor *ProcessSupervisor
 }
 
 // Setup starts vtctld process with required arguements
 func (vttablet *VttabletProcess) Setup() (err error) {
-
-	vttablet.proc = exec.Command(
-		vttablet.Binary,
+	args := []string{
 		"-topo_implementation", vttablet.CommonArg.TopoImplementation,
 		"-topo_global_server_address", vttablet.CommonArg.TopoGlobalAddress,
 		"-topo_global_root", vttablet.CommonArg.TopoGlobalRoot,
@@ -84,86 +85,63 @@ func (vttablet *VttabletProcess) Setup() (err error) {
 		"-health_check_interval", fmt.Sprintf("%ds", vttablet.HealthCheckInterval),
 		"-enable_semi_sync",
 		"-enable_replication_reporter",
-		"-backup_storage_implementation", vttablet.BackupStorageImplementation,
-		"-file_backup_storage_root", vttablet.FileBackupStorageRoot,
 		"-restore_from_backup",
 		"-service_map", vttablet.ServiceMap,
 		"-vtctld_addr", vttablet.VtctldAddress,
+	}
+	if vttablet.BackupStorage != nil {
+		args = append(args, vttablet.BackupStorage.Flags()...)
+	} else {
+		args = append(args,
+			"-backup_storage_implementation", vttablet.BackupStorageImplementation,
+			"-file_backup_storage_root", vttablet.FileBackupStorageRoot,
+		)
+	}
+	args = append(args, vttablet.ExtraArgs...)
+
+	log.Infof("%v %v", vttablet.Binary, strings.Join(args, " "))
+
+	vttablet.Supervisor, err = NewProcessSupervisor(
+		fmt.Sprintf("vttablet-%d", vttablet.TabletUID), vttablet.LogDir, vttablet.MaxRestarts,
+		func() *exec.Cmd {
+			cmd := exec.Command(vttablet.Binary, args...)
+			cmd.Env = append(cmd.Env, os.Environ()...)
+			if vttablet.BackupStorage != nil {
+				cmd.Env = append(cmd.Env, vttablet.BackupStorage.Env()...)
+			}
+			return cmd
+		},
 	)
-	vttablet.proc.Args = append(vttablet.proc.Args, vttablet.ExtraArgs...)
-
-	vttablet.proc.Stderr = os.Stderr
-	vttablet.proc.Stdout = os.Stdout
-
-	vttablet.proc.Env = append(vttablet.proc.Env, os.Environ()...)
-
-	log.Infof("%v %v", strings.Join(vttablet.proc.Args, " "))
-
-	err = vttablet.proc.Start()
 	if err != nil {
-		return
+		return err
 	}
 
-	vttablet.exit = make(chan error)
-	go func() {
-		vttablet.exit <- vttablet.proc.Wait()
-	}()
-
-	timeout := time.Now().Add(60 * time.Second)
-	for time.Now().Before(timeout) {
-		if vttablet.WaitForStatus("NOT_SERVING") {
-			return nil
-		}
-		select {
-		case err := <-vttablet.exit:
-			return fmt.Errorf("process '%s' exited prematurely (err: %s)", vttablet.Name, err)
-		default:
-			time.Sleep(300 * time.Millisecond)
-		}
+	if err = vttablet.Supervisor.Start(); err != nil {
+		return err
 	}
 
-	return fmt.Errorf("process '%s' timed out after 60s (err: %s)", vttablet.Name, <-vttablet.exit)
+	_, err = vttablet.waitForVars(60*time.Second, func(vars *TabletVars) bool {
+		return vars.TabletStateName == "NOT_SERVING"
+	})
+	return err
 }
 
 // WaitForStatus function checks if vttablet process is up and running
 func (vttablet *VttabletProcess) WaitForStatus(status string) bool {
-	resp, err := http.Get(vttablet.VerifyURL)
+	vars, err := vttablet.GetVars()
 	if err != nil {
 		return false
 	}
-	if resp.StatusCode == 200 {
-		resultMap := make(map[string]interface{})
-		respByte, _ := ioutil.ReadAll(resp.Body)
-		err := json.Unmarshal(respByte, &resultMap)
-		if err != nil {
-			panic(err)
-		}
-		return resultMap["TabletStateName"] == status
-	}
-	return false
+	return vars.TabletStateName == status
 }
 
 // TearDown shuts down the running vttablet service
 func (vttablet *VttabletProcess) TearDown() error {
-	if vttablet.proc == nil {
+	if vttablet.Supervisor == nil {
 		fmt.Printf("No process found for vttablet %d", vttablet.TabletUID)
-	}
-	if vttablet.proc == nil || vttablet.exit == nil {
 		return nil
 	}
-	// Attempt graceful shutdown with SIGTERM first
-	vttablet.proc.Process.Signal(syscall.SIGTERM)
-
-	select {
-	case <-vttablet.exit:
-		vttablet.proc = nil
-		return nil
-
-	case <-time.After(10 * time.Second):
-		vttablet.proc.Process.Kill()
-		vttablet.proc = nil
-		return <-vttablet.exit
-	}
+	return vttablet.Supervisor.TearDown()
 }
 
 // VttabletProcessInstance returns a VttabletProcess handle for vttablet process